@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+var (
+	adminAddr      = flag.String("admin-addr", ":9090", "address for the admin HTTP server (healthz/readyz/metrics)")
+	maxConnections = flag.Int("max-connections", 0, "readyz fails once active connections exceed this (0 = unlimited)")
+)
+
+// hubReady is set once the hub's run loop has started, so readyz can fail
+// fast during startup instead of reporting healthy before the broadcaster
+// is actually listening.
+var hubReady int32
+
+func markHubReady() {
+	atomic.StoreInt32(&hubReady, 1)
+}
+
+// startAdminServer serves /healthz, /readyz, and /metrics on its own
+// ServeMux and port so it never collides with the WebSocket routes.
+func startAdminServer(addr string) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&hubReady) == 0 {
+			http.Error(w, "broadcaster not started", http.StatusServiceUnavailable)
+			return
+		}
+		if *maxConnections > 0 && atomic.LoadInt64(&metrics.connectionsActive) > int64(*maxConnections) {
+			http.Error(w, "connection cap exceeded", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metrics.render(w)
+	})
+
+	log.Println("Admin server running on", addr)
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
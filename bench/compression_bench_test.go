@@ -0,0 +1,138 @@
+// Package bench measures the cost/benefit of gorilla's permessage-deflate
+// compression for the message sizes this server actually relays: small
+// chat lines, larger JSON chat history payloads, and file-chunk-sized
+// broadcasts. It runs against a minimal echo server rather than importing
+// package main, since main isn't an importable package.
+package bench
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// payloadSizes are representative message sizes: a short chat line, a
+// chat-history JSON blob, and a file chunk.
+var payloadSizes = []int{64, 4096, 65536}
+
+// newEchoServer starts a websocket server that echoes every message it
+// receives back to the sender, with compression negotiation controlled by
+// enableCompression.
+func newEchoServer(enableCompression bool) *httptest.Server {
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       func(r *http.Request) bool { return true },
+		EnableCompression: enableCompression,
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.EnableWriteCompression(enableCompression)
+			if err := conn.WriteMessage(mt, message); err != nil {
+				return
+			}
+		}
+	})
+	return httptest.NewServer(handler)
+}
+
+// countingConn wraps a net.Conn and tallies bytes actually written to it, so
+// benchmarks can report real bytes-on-wire instead of the logical payload
+// size passed to WriteMessage.
+type countingConn struct {
+	net.Conn
+	written *int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+// dial opens a client connection to srv, negotiating compression when
+// enableCompression is set. written accumulates the bytes the client writes
+// to the wire across every call made on the returned connection.
+func dial(b *testing.B, srv *httptest.Server, enableCompression bool, written *int64) *websocket.Conn {
+	b.Helper()
+	dialer := websocket.Dialer{
+		EnableCompression: enableCompression,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &countingConn{Conn: conn, written: written}, nil
+		},
+	}
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		b.Fatalf("dial: %v", err)
+	}
+	return conn
+}
+
+func runEchoBenchmark(b *testing.B, size int, enableCompression bool) {
+	srv := newEchoServer(enableCompression)
+	defer srv.Close()
+	var written int64
+	conn := dial(b, srv, enableCompression, &written)
+	defer conn.Close()
+
+	// A realistic payload: JSON-ish repeated text rather than random bytes,
+	// since deflate's ratio on incompressible data isn't representative of
+	// chat/file-chunk traffic.
+	const phrase = "the quick brown fox jumps over the lazy dog "
+	payload := []byte(strings.Repeat(phrase, size/len(phrase)+2)[:size])
+
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+			b.Fatalf("write: %v", err)
+		}
+		if _, _, err := conn.ReadMessage(); err != nil {
+			b.Fatalf("read: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	wireBytes := atomic.LoadInt64(&written)
+	b.ReportMetric(float64(wireBytes)/float64(b.N), "wire-bytes/op")
+	b.ReportMetric(float64(wireBytes)/float64(int64(b.N)*int64(size)), "wire-bytes/logical-byte")
+}
+
+func BenchmarkEchoUncompressed(b *testing.B) {
+	for _, size := range payloadSizes {
+		b.Run(sizeLabel(size), func(b *testing.B) {
+			runEchoBenchmark(b, size, false)
+		})
+	}
+}
+
+func BenchmarkEchoCompressed(b *testing.B) {
+	for _, size := range payloadSizes {
+		b.Run(sizeLabel(size), func(b *testing.B) {
+			runEchoBenchmark(b, size, true)
+		})
+	}
+}
+
+func sizeLabel(size int) string {
+	return fmt.Sprintf("size=%d", size)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "sync"
+
+// bufferPool is a sync.Pool-backed websocket.WriteBufferPool so
+// high-connection deployments don't allocate a fresh write buffer for every
+// connection.
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	return &bufferPool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return make([]byte, size)
+			},
+		},
+	}
+}
+
+// Get and Put satisfy websocket.BufferPool, which is defined in terms of
+// interface{} rather than []byte. Get returns whatever was Put without a type
+// assertion, since the websocket package stores its own wrapper type here.
+func (p *bufferPool) Get() interface{} {
+	return p.pool.Get()
+}
+
+func (p *bufferPool) Put(buf interface{}) {
+	p.pool.Put(buf)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer.
+	pongWait = 60 * time.Second
+
+	// Send pings to the peer with this period. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum message size allowed from the peer.
+	maxMessageSize = 8192
+
+	// sendBufferSize is how many outbound messages can queue for a client
+	// before it's considered slow and dropped by the hub.
+	sendBufferSize = 256
+)
+
+// envelope is the JSON message format exchanged over the WebSocket
+// connection. Channel selects the room a message targets, Type
+// distinguishes control messages (join/leave) from chat payloads, and
+// Payload carries the body.
+type envelope struct {
+	Channel string `json:"channel"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+}
+
+// Client is a middleman between the websocket connection and either the
+// Hub (chat) or the WorkerPool (work queue). Exactly one of hub or pool is
+// set, depending on which endpoint the connection came in on.
+type Client struct {
+	hub  *Hub
+	pool *WorkerPool
+
+	conn *websocket.Conn
+
+	// send is a buffered channel of outbound messages; writePump is the
+	// only goroutine allowed to write to conn, so every write funnels
+	// through here instead of racing with the hub/pool or the ping ticker.
+	send chan []byte
+}
+
+// readPump pumps messages from the websocket connection to the hub.
+//
+// The application runs readPump in a per-connection goroutine. readPump
+// ensures there is at most one reader on a connection by executing all
+// reads from this goroutine.
+func (c *Client) readPump() {
+	defer func() {
+		if c.pool != nil {
+			c.pool.unregister(c)
+		} else {
+			c.hub.unregister <- subscription{client: c}
+		}
+		c.conn.Close()
+		log.Println("Client disconnected")
+	}()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Println("Error reading message:", err)
+			}
+			break
+		}
+		message = bytes.TrimSpace(message)
+
+		if c.pool != nil {
+			c.handleJobMessage(message)
+			continue
+		}
+
+		var env envelope
+		if err := json.Unmarshal(message, &env); err != nil {
+			log.Println("Malformed message:", err)
+			c.sendError("malformed JSON envelope")
+			continue
+		}
+		if env.Channel == "" {
+			env.Channel = defaultChannel
+		}
+
+		switch env.Type {
+		case "join":
+			c.hub.register <- subscription{client: c, channel: env.Channel}
+		case "leave":
+			c.hub.unregister <- subscription{client: c, channel: env.Channel}
+		case "msg":
+			log.Println("Received message:", env.Payload)
+			c.hub.broadcast <- broadcastMsg{sender: c, channel: env.Channel, env: env}
+		default:
+			c.sendError("unknown message type: " + env.Type)
+		}
+	}
+}
+
+// handleJobMessage processes a message from a /ws/work connection: "job"
+// submits work to the pool for round-robin dispatch, "ack" acknowledges a
+// previously dispatched job. dispatch runs in its own goroutine since it
+// blocks for up to ackTimeout per worker tried and must not stall reads.
+func (c *Client) handleJobMessage(message []byte) {
+	var env jobEnvelope
+	if err := json.Unmarshal(message, &env); err != nil {
+		log.Println("Malformed job message:", err)
+		return
+	}
+	switch env.Type {
+	case "job":
+		go c.pool.dispatch(env.ID, env.Payload)
+	case "ack":
+		c.pool.ack(env.ID)
+	default:
+		log.Println("unknown job message type:", env.Type)
+	}
+}
+
+// writePump pumps messages from the hub to the websocket connection, and
+// pings the peer on an interval to keep the connection alive.
+//
+// A goroutine running writePump is started for each connection. writePump
+// ensures there is at most one writer to a connection by executing all
+// writes from this goroutine.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The hub closed the channel.
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			// Keep small control frames uncompressed; only pay the deflate
+			// cost on payloads large enough for it to pay off.
+			c.conn.EnableWriteCompression(len(message) >= *compressionMinSize)
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Println("Error writing message:", err)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Println("Ping error:", err)
+				metrics.pingFailed()
+				return
+			}
+		}
+	}
+}
+
+// sendError queues an error frame describing a malformed or unknown message
+// instead of broadcasting it.
+func (c *Client) sendError(reason string) {
+	errEnv := envelope{Channel: defaultChannel, Type: "error", Payload: reason}
+	data, err := json.Marshal(errEnv)
+	if err != nil {
+		log.Println("Error marshalling error frame:", err)
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		log.Println("Dropping error frame: send buffer full")
+	}
+}
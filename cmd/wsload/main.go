@@ -0,0 +1,202 @@
+// Command wsload opens N concurrent WebSocket clients against a running
+// server, has each send randomized chat messages at a configurable rate,
+// and verifies that every other client actually receives them. It exists
+// to give a reproducible way to exercise the hub refactor and compression
+// support under load.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// envelope mirrors the server's wire format (see the root package's
+// envelope type) so this client can speak the same protocol without
+// importing package main, which isn't importable.
+type envelope struct {
+	Channel string `json:"channel"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+}
+
+// chatPayload is JSON-encoded into envelope.Payload so receivers can
+// measure end-to-end latency and count drops.
+type chatPayload struct {
+	ID     string `json:"id"`
+	SentAt int64  `json:"sent_at"` // UnixNano
+	Filler string `json:"filler"`
+}
+
+var (
+	addr        = flag.String("addr", "ws://localhost:8080/ws", "WebSocket server address to load test")
+	numClients  = flag.Int("clients", 10, "number of concurrent WebSocket clients")
+	rate        = flag.Float64("rate", 5, "messages sent per second, per client")
+	duration    = flag.Duration("duration", 10*time.Second, "how long to run the load test")
+	payloadSize = flag.Int("payload-size", 64, "random filler bytes appended to each message")
+)
+
+// stats accumulates send/receive counts and delivery latencies across all
+// client goroutines.
+type stats struct {
+	sent     int64
+	received int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (s *stats) recordLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latencies = append(s.latencies, d)
+	s.mu.Unlock()
+}
+
+func main() {
+	flag.Parse()
+
+	s := &stats{}
+	var wg sync.WaitGroup
+	for i := 0; i < *numClients; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			runClient(id, s)
+		}(i)
+	}
+	wg.Wait()
+
+	report(s)
+}
+
+// runClient dials the server, reads broadcast messages in the background,
+// and writes randomized messages at the configured rate until duration
+// elapses.
+func runClient(id int, s *stats) {
+	conn, _, err := websocket.DefaultDialer.Dial(*addr, nil)
+	if err != nil {
+		log.Printf("client %d: dial error: %v", id, err)
+		return
+	}
+	defer conn.Close()
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var env envelope
+			if err := json.Unmarshal(message, &env); err != nil || env.Type != "msg" {
+				continue
+			}
+			var cp chatPayload
+			if err := json.Unmarshal([]byte(env.Payload), &cp); err != nil {
+				continue // e.g. the server's plain-text welcome message
+			}
+			atomic.AddInt64(&s.received, 1)
+			s.recordLatency(time.Since(time.Unix(0, cp.SentAt)))
+		}
+	}()
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+
+	for {
+		select {
+		case <-deadline:
+			conn.Close()
+			<-readDone
+			return
+		case <-ticker.C:
+			if err := sendMessage(conn); err != nil {
+				return
+			}
+			atomic.AddInt64(&s.sent, 1)
+		}
+	}
+}
+
+func sendMessage(conn *websocket.Conn) error {
+	cp := chatPayload{ID: randomHex(8), SentAt: time.Now().UnixNano(), Filler: randomString(*payloadSize)}
+	payload, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(envelope{Channel: defaultChannel, Type: "msg", Payload: string(payload)})
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// defaultChannel matches the server's default room so load-test traffic
+// reaches every other connected client without an explicit join.
+const defaultChannel = "#all"
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+func randomString(n int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		idx, _ := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		sb.WriteByte(alphabet[idx.Int64()])
+	}
+	return sb.String()
+}
+
+// report prints latency percentiles and drop counts. Every sent message is
+// expected to reach every other client once, so drops = expected - actual.
+func report(s *stats) {
+	sent := atomic.LoadInt64(&s.sent)
+	received := atomic.LoadInt64(&s.received)
+	expected := sent * int64(*numClients-1)
+	drops := expected - received
+	if drops < 0 {
+		drops = 0
+	}
+
+	s.mu.Lock()
+	latencies := append([]time.Duration(nil), s.latencies...)
+	s.mu.Unlock()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Println("=== wsload results ===")
+	fmt.Printf("clients: %d  sent: %d  received: %d  drops: %d\n", *numClients, sent, received, drops)
+	if len(latencies) == 0 {
+		fmt.Println("no deliveries observed")
+		return
+	}
+	fmt.Printf("p50: %s  p95: %s  p99: %s  max: %s\n",
+		percentile(latencies, 0.50),
+		percentile(latencies, 0.95),
+		percentile(latencies, 0.99),
+		latencies[len(latencies)-1],
+	)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// subscription requests a client join or leave a channel. An empty channel
+// on an unregister request means "leave every channel the client belongs
+// to", which is how disconnects are modeled.
+type subscription struct {
+	client  *Client
+	channel string
+}
+
+type broadcastMsg struct {
+	sender  *Client
+	channel string
+	env     envelope
+}
+
+// Hub owns the set of active clients grouped by channel and routes messages
+// between them. All client bookkeeping is funneled through its channels so
+// no lock is needed: only the run goroutine ever touches clients.
+type Hub struct {
+	// clients maps channel name to the set of clients subscribed to it.
+	clients map[string]map[*Client]bool
+
+	// broadcast routes an incoming message to its target channel.
+	broadcast chan broadcastMsg
+
+	// register requests a client join a channel.
+	register chan subscription
+
+	// unregister requests a client leave a channel, or every channel.
+	unregister chan subscription
+
+	// registered tracks clients with a live send channel, independent of
+	// which channels they currently belong to. It's the liveness sentinel
+	// leaveAll uses to decide whether a client has already been cleaned up,
+	// since a client may leave every named channel (including
+	// defaultChannel) while still being connected.
+	registered map[*Client]bool
+}
+
+func newHub() *Hub {
+	return &Hub{
+		clients:    make(map[string]map[*Client]bool),
+		broadcast:  make(chan broadcastMsg),
+		register:   make(chan subscription),
+		unregister: make(chan subscription),
+		registered: make(map[*Client]bool),
+	}
+}
+
+// run owns the hub's state; it must be started exactly once as a goroutine.
+func (h *Hub) run() {
+	markHubReady()
+	for {
+		select {
+		case sub := <-h.register:
+			h.join(sub.client, sub.channel)
+		case sub := <-h.unregister:
+			if sub.channel == "" {
+				h.leaveAll(sub.client)
+			} else {
+				h.leave(sub.client, sub.channel)
+			}
+		case msg := <-h.broadcast:
+			h.deliver(msg)
+		}
+	}
+}
+
+func (h *Hub) join(client *Client, channel string) {
+	if h.clients[channel] == nil {
+		h.clients[channel] = make(map[*Client]bool)
+	}
+	h.clients[channel][client] = true
+	h.registered[client] = true
+}
+
+func (h *Hub) leave(client *Client, channel string) {
+	if _, ok := h.clients[channel][client]; !ok {
+		return
+	}
+	delete(h.clients[channel], client)
+	if len(h.clients[channel]) == 0 && channel != defaultChannel {
+		delete(h.clients, channel)
+	}
+}
+
+// leaveAll removes client from every channel and closes its send channel.
+// It is a no-op if the client was already removed, which keeps it safe to
+// call both when a slow client is dropped and when it later disconnects.
+func (h *Hub) leaveAll(client *Client) {
+	if !h.registered[client] {
+		return
+	}
+	delete(h.registered, client)
+	for channel := range h.clients {
+		h.leave(client, channel)
+	}
+	close(client.send)
+	metrics.connectionClosed()
+}
+
+// deliver fans a message out to every peer subscribed to its target channel.
+// A client whose send buffer is full is considered slow and dropped instead
+// of blocking the rest of the fan-out.
+func (h *Hub) deliver(msg broadcastMsg) {
+	start := time.Now()
+	defer func() { metrics.fanoutLatency.Observe(time.Since(start)) }()
+	metrics.messageBroadcast()
+
+	payload, err := json.Marshal(msg.env)
+	if err != nil {
+		log.Println("Error marshalling broadcast message:", err)
+		return
+	}
+	for client := range h.clients[msg.channel] {
+		if client == msg.sender {
+			continue // Skip sending back to the sender
+		}
+		select {
+		case client.send <- payload:
+		default:
+			log.Println("Dropping slow client: send buffer full")
+			metrics.broadcastDropped()
+			h.leaveAll(client)
+		}
+	}
+}
@@ -1,113 +1,105 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// WebSocket upgrader to convert HTTP connections to WebSocket
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins
-	},
-}
+// upgrader converts HTTP connections to WebSocket. It's built by
+// configureUpgrader once flags have been parsed, since CheckOrigin,
+// Subprotocols, and the buffer sizes are all configuration-driven.
+var upgrader websocket.Upgrader
+
+// defaultChannel is the channel every connection joins automatically on connect.
+const defaultChannel = "#all"
+
+// compressionLevel is the flate compression level used for connections that
+// negotiated permessage-deflate. 1 favors speed over ratio, which suits a
+// broadcast server relaying many small, latency-sensitive messages.
+const compressionLevel = 1
 
-// Global variables to manage connected clients and broadcasting messages
 var (
-	clients   = make(map[*websocket.Conn]bool) // Tracks active WebSocket connections
-	broadcast = make(chan struct {
-		sender  *websocket.Conn
-		message []byte
-	})
-	mutex sync.Mutex // Mutex for safe access to shared data
+	// compressionMinSize is the minimum payload size, in bytes, below which
+	// outgoing messages are sent uncompressed so small control frames don't
+	// pay the deflate overhead.
+	compressionMinSize = flag.Int("compression-min-size", 1024, "minimum payload size in bytes before per-message compression is enabled")
+
+	allowedOrigins   = flag.String("allowed-origins", "*", "comma-separated list of origins allowed to upgrade (supports \"*\" and \"*.example.com\" wildcards)")
+	subprotocols     = flag.String("subprotocols", "", "comma-separated list of WebSocket subprotocols advertised, in order of preference")
+	handshakeTimeout = flag.Duration("handshake-timeout", 10*time.Second, "time allowed to complete the WebSocket handshake")
+	readBufferSize   = flag.Int("read-buffer-size", 4096, "upgrader read buffer size in bytes")
+	writeBufferSize  = flag.Int("write-buffer-size", 4096, "upgrader write buffer size in bytes")
 )
 
-// WebSocket handler: Manages individual client connections
-func wsHandler(w http.ResponseWriter, r *http.Request) {
-	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("WebSocket upgrade error:", err)
-		return
-	}
-	defer func() {
-		mutex.Lock()
-		delete(clients, conn) // Remove client on disconnect
-		mutex.Unlock()
-		conn.Close()
-		log.Println("Client disconnected")
-	}()
-
-	// Register the new client
-	mutex.Lock()
-	clients[conn] = true
-	mutex.Unlock()
-	log.Println("New client connected")
-
-	// Send a welcome message
-	if err := conn.WriteMessage(websocket.TextMessage, []byte("Welcome to the WebSocket server!")); err != nil {
-		log.Println("Error sending welcome message:", err)
-		return
+// configureUpgrader builds the package-level upgrader from flag values.
+// Must be called after flag.Parse().
+func configureUpgrader() {
+	upgrader = websocket.Upgrader{
+		CheckOrigin:       buildOriginChecker(splitCSV(*allowedOrigins)),
+		Subprotocols:      splitCSV(*subprotocols),
+		HandshakeTimeout:  *handshakeTimeout,
+		ReadBufferSize:    *readBufferSize,
+		WriteBufferSize:   *writeBufferSize,
+		WriteBufferPool:   newBufferPool(*writeBufferSize),
+		EnableCompression: true,
 	}
+}
 
-	// Continuously read messages from the client
-	for {
-		_, message, err := conn.ReadMessage()
+// wsHandler upgrades the connection, registers a Client with the hub, and
+// starts its read/write pumps. All per-connection state and messaging lives
+// on the Client; this handler just wires it up.
+func wsHandler(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			log.Println("Error reading message:", err)
-			break
+			log.Println("WebSocket upgrade error:", err)
+			return
 		}
-		log.Println("Received message:", string(message))
-		broadcast <- struct {
-			sender  *websocket.Conn
-			message []byte
-		}{sender: conn, message: message} // Send sender info with the message
-	}
-}
+		conn.SetCompressionLevel(compressionLevel)
+
+		client := &Client{hub: hub, conn: conn, send: make(chan []byte, sendBufferSize)}
+		hub.register <- subscription{client: client, channel: defaultChannel}
+		metrics.connectionOpened()
+		log.Println("New client connected")
 
-// Broadcasts messages to all connected clients
-func handleBroadcast() {
-	for {
-		// Receive message from the broadcast channel
-		data := <-broadcast
-		sender := data.sender
-		message := data.message
-
-		mutex.Lock()
-		for client := range clients {
-			if client != sender { // Skip sending back to the sender
-				if err := client.WriteMessage(websocket.TextMessage, message); err != nil {
-					log.Println("Error broadcasting message:", err)
-					client.Close()
-					delete(clients, client) // Remove disconnected clients
-				}
-			}
+		welcome := envelope{Channel: defaultChannel, Type: "msg", Payload: "Welcome to the WebSocket server!"}
+		data, err := json.Marshal(welcome)
+		if err != nil {
+			log.Println("Error marshalling welcome message:", err)
+		} else {
+			client.send <- data
 		}
-		mutex.Unlock()
+
+		go client.writePump()
+		go client.readPump()
 	}
 }
 
-// Sends periodic ping messages to maintain WebSocket connections
-func keepAlive() {
-	ticker := time.NewTicker(30 * time.Second) // Pings every 30 seconds
-	defer ticker.Stop()
-
-	for {
-		<-ticker.C
-		mutex.Lock()
-		for client := range clients {
-			if err := client.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Println("Ping error:", err)
-				client.Close()
-				delete(clients, client) // Remove unresponsive clients
-			}
+// wsWorkHandler upgrades the connection and registers a Client with the
+// worker pool instead of the chat hub. Connections to this endpoint both
+// submit jobs (type "job") and receive work dispatched round-robin.
+func wsWorkHandler(pool *WorkerPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Println("WebSocket upgrade error:", err)
+			return
 		}
-		mutex.Unlock()
+		conn.SetCompressionLevel(compressionLevel)
+
+		client := &Client{pool: pool, conn: conn, send: make(chan []byte, sendBufferSize)}
+		pool.register(client)
+		metrics.connectionOpened()
+		log.Println("New worker connected")
+
+		go client.writePump()
+		go client.readPump()
 	}
 }
 
@@ -117,19 +109,25 @@ func homePage(w http.ResponseWriter, r *http.Request) {
 }
 
 // Set up HTTP routes
-func setupRoutes() {
+func setupRoutes(hub *Hub, pool *WorkerPool) {
 	http.HandleFunc("/", homePage)
-	http.HandleFunc("/ws", wsHandler)
+	http.HandleFunc("/ws", wsHandler(hub))
+	http.HandleFunc("/ws/work", wsWorkHandler(pool))
 }
 
 func main() {
+	flag.Parse()
+	configureUpgrader()
 	fmt.Println("WebSocket server running on :8080")
 
-	// Start the broadcaster and keep-alive mechanisms
-	go handleBroadcast()
-	go keepAlive()
+	hub := newHub()
+	go hub.run()
+
+	pool := newWorkerPool()
+
+	startAdminServer(*adminAddr)
 
 	// Set up routes and start the HTTP server
-	setupRoutes()
+	setupRoutes(hub, pool)
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metrics is the process-wide counters and gauges exposed at /metrics in
+// Prometheus text exposition format by the admin server.
+var metrics = newMetricsRegistry()
+
+// metricsRegistry tracks connection and broadcast counters with plain
+// atomics rather than a client library, since this is the module's only
+// metric surface and doesn't warrant a new dependency.
+type metricsRegistry struct {
+	connectionsActive int64
+	messagesBroadcast int64
+	broadcastDrops    int64
+	pingFailures      int64
+
+	fanoutLatency *latencyHistogram
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{fanoutLatency: newLatencyHistogram()}
+}
+
+func (m *metricsRegistry) connectionOpened() { atomic.AddInt64(&m.connectionsActive, 1) }
+func (m *metricsRegistry) connectionClosed() { atomic.AddInt64(&m.connectionsActive, -1) }
+func (m *metricsRegistry) messageBroadcast() { atomic.AddInt64(&m.messagesBroadcast, 1) }
+func (m *metricsRegistry) broadcastDropped() { atomic.AddInt64(&m.broadcastDrops, 1) }
+func (m *metricsRegistry) pingFailed()       { atomic.AddInt64(&m.pingFailures, 1) }
+
+// render writes every metric to w in Prometheus text exposition format.
+func (m *metricsRegistry) render(w io.Writer) {
+	fmt.Fprintf(w, "# TYPE ws_connections_active gauge\nws_connections_active %d\n", atomic.LoadInt64(&m.connectionsActive))
+	fmt.Fprintf(w, "# TYPE ws_messages_broadcast_total counter\nws_messages_broadcast_total %d\n", atomic.LoadInt64(&m.messagesBroadcast))
+	fmt.Fprintf(w, "# TYPE ws_broadcast_drops_total counter\nws_broadcast_drops_total %d\n", atomic.LoadInt64(&m.broadcastDrops))
+	fmt.Fprintf(w, "# TYPE ws_ping_failures_total counter\nws_ping_failures_total %d\n", atomic.LoadInt64(&m.pingFailures))
+	m.fanoutLatency.render(w)
+}
+
+// fanoutBuckets are histogram upper bounds, in seconds, for broadcast
+// fan-out latency.
+var fanoutBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// latencyHistogram is a minimal Prometheus-style histogram: each bucket
+// holds the cumulative count of observations at or below its bound.
+type latencyHistogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{buckets: fanoutBuckets, counts: make([]uint64, len(fanoutBuckets))}
+}
+
+func (h *latencyHistogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += seconds
+	h.count++
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+func (h *latencyHistogram) render(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintln(w, "# TYPE ws_broadcast_fanout_seconds histogram")
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "ws_broadcast_fanout_seconds_bucket{le=\"%g\"} %d\n", bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "ws_broadcast_fanout_seconds_bucket{le=\"+Inf\"} %d\n", h.count)
+	fmt.Fprintf(w, "ws_broadcast_fanout_seconds_sum %g\n", h.sum)
+	fmt.Fprintf(w, "ws_broadcast_fanout_seconds_count %d\n", h.count)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// buildOriginChecker returns a CheckOrigin function that allows only the
+// given origins. An allowed value of "*" allows every origin; a value
+// starting with "*." matches any subdomain of that domain, but not the bare
+// domain itself.
+func buildOriginChecker(allowed []string) func(*http.Request) bool {
+	for _, o := range allowed {
+		if o == "*" {
+			return func(r *http.Request) bool { return true }
+		}
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return false
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		for _, pattern := range allowed {
+			if matchOrigin(pattern, origin, u.Host) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchOrigin reports whether the request's origin satisfies pattern.
+// Patterns are matched against the full scheme+host[:port] origin string
+// (e.g. "https://chat.example.com"), matching how browsers populate the
+// Origin header and how CORS/Fetch define "origin". A pattern starting with
+// "*." is the one exception: it matches any subdomain of that domain by host
+// alone, regardless of scheme (e.g. "*.example.com" matches both
+// "https://chat.example.com" and "wss://chat.example.com", but not
+// "https://example.com").
+func matchOrigin(pattern, origin, host string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == origin
+}
+
+// splitCSV splits a comma-separated flag value into trimmed, non-empty
+// parts.
+func splitCSV(s string) []string {
+	var parts []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
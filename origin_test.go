@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		origin  string
+		host    string
+		want    bool
+	}{
+		{"exact match", "https://chat.example.com", "https://chat.example.com", "chat.example.com", true},
+		{"scheme mismatch", "https://chat.example.com", "http://chat.example.com", "chat.example.com", false},
+		{"port mismatch", "https://chat.example.com", "https://chat.example.com:8443", "chat.example.com:8443", false},
+		{"port match", "https://chat.example.com:8443", "https://chat.example.com:8443", "chat.example.com:8443", true},
+		{"bare host does not match full origin", "chat.example.com", "https://chat.example.com", "chat.example.com", false},
+		{"wildcard matches subdomain regardless of scheme", "*.example.com", "wss://chat.example.com", "chat.example.com", true},
+		{"wildcard does not match bare domain", "*.example.com", "https://example.com", "example.com", false},
+		{"unrelated origin", "https://chat.example.com", "https://evil.com", "evil.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchOrigin(tt.pattern, tt.origin, tt.host); got != tt.want {
+				t.Errorf("matchOrigin(%q, %q, %q) = %v, want %v", tt.pattern, tt.origin, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildOriginChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"star allows everything", []string{"*"}, "https://anything.example.com", true},
+		{"exact origin allowed", []string{"https://chat.example.com"}, "https://chat.example.com", true},
+		{"bare host configured the wrong way is rejected", []string{"chat.example.com"}, "https://chat.example.com", false},
+		{"different scheme rejected", []string{"https://chat.example.com"}, "http://chat.example.com", false},
+		{"subdomain wildcard allowed", []string{"*.example.com"}, "https://chat.example.com", true},
+		{"no origin header rejected", []string{"*.example.com"}, "", false},
+		{"unmatched origin rejected", []string{"https://chat.example.com"}, "https://evil.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := buildOriginChecker(tt.allowed)
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := checker(req); got != tt.want {
+				t.Errorf("buildOriginChecker(%v)(origin=%q) = %v, want %v", tt.allowed, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
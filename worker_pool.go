@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ackTimeout is how long dispatch waits for a worker to ack a job before
+// requeuing it to the next worker in the rotation.
+const ackTimeout = 5 * time.Second
+
+// jobEnvelope is the message format exchanged on /ws/work. Type is "job"
+// for work submitted to the pool and "ack" for a worker's acknowledgement.
+type jobEnvelope struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`
+}
+
+// WorkerPool dispatches jobs to exactly one connected worker at a time, in
+// round-robin order, instead of fanning them out to everyone like the Hub
+// does. A job that isn't acked within ackTimeout is requeued to the next
+// worker in the rotation.
+type WorkerPool struct {
+	mu      sync.Mutex
+	workers []*Client
+	cursor  uint64
+
+	acksMu sync.Mutex
+	acks   map[string]chan struct{}
+}
+
+func newWorkerPool() *WorkerPool {
+	return &WorkerPool{acks: make(map[string]chan struct{})}
+}
+
+// register adds a worker to the rotation.
+func (p *WorkerPool) register(c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.workers = append(p.workers, c)
+}
+
+// unregister removes a worker from the rotation, e.g. on disconnect.
+func (p *WorkerPool) unregister(c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, w := range p.workers {
+		if w == c {
+			p.workers = append(p.workers[:i], p.workers[i+1:]...)
+			metrics.connectionClosed()
+			return
+		}
+	}
+}
+
+// ack records that jobID was acknowledged by a worker.
+func (p *WorkerPool) ack(jobID string) {
+	p.acksMu.Lock()
+	waiter, ok := p.acks[jobID]
+	p.acksMu.Unlock()
+	if ok {
+		close(waiter)
+	}
+}
+
+// snapshot returns the current worker rotation so dispatch can iterate it
+// without holding the lock for the duration of a job.
+func (p *WorkerPool) snapshot() []*Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	workers := make([]*Client, len(p.workers))
+	copy(workers, p.workers)
+	return workers
+}
+
+// dispatch delivers payload to the next live worker in round-robin order.
+// If no ack arrives within ackTimeout the job is requeued to the next
+// worker, until every worker in the current rotation has been tried once.
+func (p *WorkerPool) dispatch(jobID, payload string) {
+	workers := p.snapshot()
+	if len(workers) == 0 {
+		log.Println("no workers available, dropping job", jobID)
+		return
+	}
+
+	waiter := make(chan struct{})
+	p.acksMu.Lock()
+	p.acks[jobID] = waiter
+	p.acksMu.Unlock()
+	defer func() {
+		p.acksMu.Lock()
+		delete(p.acks, jobID)
+		p.acksMu.Unlock()
+	}()
+
+	data, err := json.Marshal(jobEnvelope{ID: jobID, Type: "job", Payload: payload})
+	if err != nil {
+		log.Println("Error marshalling job:", err)
+		return
+	}
+
+	for attempt := 0; attempt < len(workers); attempt++ {
+		next := atomic.AddUint64(&p.cursor, 1) - 1
+		worker := workers[next%uint64(len(workers))]
+
+		select {
+		case worker.send <- data:
+		default:
+			continue // worker's send buffer is full, try the next one
+		}
+
+		select {
+		case <-waiter:
+			return
+		case <-time.After(ackTimeout):
+			log.Println("job requeued: no ack from worker", jobID)
+		}
+	}
+	log.Println("job dropped: no worker acked", jobID)
+}